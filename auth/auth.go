@@ -0,0 +1,195 @@
+// Package auth implements a simple ban-list, modeled on the ban-list idea
+// from ssh-chat: usernames, remote IPs, and (once key-based identity is
+// available) pubkey fingerprints can each be banned for a duration, with
+// entries expiring lazily like a TTL cache.
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Kind identifies what a ban entry matches against.
+type Kind string
+
+const (
+	Username Kind = "username"
+	IP       Kind = "ip"
+	Key      Kind = "key"
+)
+
+// Entry describes a single active ban, as returned by List.
+type Entry struct {
+	Kind      Kind      `json:"kind"`
+	Value     string    `json:"value"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // zero means permanent
+}
+
+// Auth tracks username, IP, and pubkey-fingerprint bans with optional
+// expiry. It is safe for concurrent use.
+type Auth struct {
+	mu   sync.RWMutex
+	path string
+
+	usernames map[string]time.Time
+	ips       map[string]time.Time
+	keys      map[string]time.Time
+}
+
+// New creates an Auth, loading any existing ban list from path. path may
+// be empty, in which case bans are kept in memory only.
+func New(path string) *Auth {
+	a := &Auth{
+		path:      path,
+		usernames: make(map[string]time.Time),
+		ips:       make(map[string]time.Time),
+		keys:      make(map[string]time.Time),
+	}
+	if path != "" {
+		if err := a.load(); err != nil {
+			fmt.Fprintf(os.Stderr, "auth: failed to load ban list from %s: %v\n", path, err)
+		}
+	}
+	return a
+}
+
+func (a *Auth) table(kind Kind) map[string]time.Time {
+	switch kind {
+	case Username:
+		return a.usernames
+	case IP:
+		return a.ips
+	case Key:
+		return a.keys
+	default:
+		return nil
+	}
+}
+
+// Ban bans value under kind for d. d <= 0 means the ban never expires.
+func (a *Auth) Ban(kind Kind, value string, d time.Duration) error {
+	table := a.table(kind)
+	if table == nil {
+		return fmt.Errorf("auth: unknown ban kind %q", kind)
+	}
+	var expiresAt time.Time
+	if d > 0 {
+		expiresAt = time.Now().Add(d)
+	}
+
+	a.mu.Lock()
+	table[value] = expiresAt
+	a.mu.Unlock()
+
+	return a.save()
+}
+
+// Unban removes a ban, returning false if value wasn't banned.
+func (a *Auth) Unban(kind Kind, value string) (bool, error) {
+	table := a.table(kind)
+	if table == nil {
+		return false, fmt.Errorf("auth: unknown ban kind %q", kind)
+	}
+
+	a.mu.Lock()
+	_, ok := table[value]
+	delete(table, value)
+	a.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	return true, a.save()
+}
+
+// IsBanned reports whether value is currently banned under kind, lazily
+// evicting the entry if it has expired.
+func (a *Auth) IsBanned(kind Kind, value string) bool {
+	table := a.table(kind)
+	if table == nil {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	expiresAt, ok := table[value]
+	if !ok {
+		return false
+	}
+	if !expiresAt.IsZero() && time.Now().After(expiresAt) {
+		delete(table, value)
+		return false
+	}
+	return true
+}
+
+// List returns all active (non-expired) bans across every kind.
+func (a *Auth) List() []Entry {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	now := time.Now()
+	var entries []Entry
+	for kind, table := range map[Kind]map[string]time.Time{Username: a.usernames, IP: a.ips, Key: a.keys} {
+		for value, expiresAt := range table {
+			if !expiresAt.IsZero() && now.After(expiresAt) {
+				continue
+			}
+			entries = append(entries, Entry{Kind: kind, Value: value, ExpiresAt: expiresAt})
+		}
+	}
+	return entries
+}
+
+type persisted struct {
+	Usernames map[string]time.Time `json:"usernames"`
+	IPs       map[string]time.Time `json:"ips"`
+	Keys      map[string]time.Time `json:"keys"`
+}
+
+func (a *Auth) load() error {
+	data, err := os.ReadFile(a.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var p persisted
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if p.Usernames != nil {
+		a.usernames = p.Usernames
+	}
+	if p.IPs != nil {
+		a.ips = p.IPs
+	}
+	if p.Keys != nil {
+		a.keys = p.Keys
+	}
+	return nil
+}
+
+func (a *Auth) save() error {
+	if a.path == "" {
+		return nil
+	}
+
+	a.mu.RLock()
+	p := persisted{Usernames: a.usernames, IPs: a.ips, Keys: a.keys}
+	data, err := json.MarshalIndent(p, "", "  ")
+	a.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(a.path, data, 0o600)
+}
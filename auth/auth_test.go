@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBanAndUnban(t *testing.T) {
+	a := New("")
+
+	if a.IsBanned(Username, "eve") {
+		t.Fatal("eve should not be banned yet")
+	}
+	if err := a.Ban(Username, "eve", 0); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	if !a.IsBanned(Username, "eve") {
+		t.Fatal("expected eve to be banned")
+	}
+
+	ok, err := a.Unban(Username, "eve")
+	if err != nil {
+		t.Fatalf("Unban: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected Unban to report a removal")
+	}
+	if a.IsBanned(Username, "eve") {
+		t.Fatal("expected eve to no longer be banned")
+	}
+}
+
+func TestBanExpiry(t *testing.T) {
+	a := New("")
+	if err := a.Ban(IP, "10.0.0.1", time.Millisecond); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if a.IsBanned(IP, "10.0.0.1") {
+		t.Fatal("expected expired ban to no longer be active")
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+
+	a := New(path)
+	if err := a.Ban(Key, "abc123", 0); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+
+	reloaded := New(path)
+	if !reloaded.IsBanned(Key, "abc123") {
+		t.Fatal("expected ban to survive reload from disk")
+	}
+}
+
+func TestList(t *testing.T) {
+	a := New("")
+	a.Ban(Username, "eve", 0)
+	a.Ban(IP, "10.0.0.1", 0)
+
+	entries := a.List()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"net"
+	"sync"
+	"testing"
+)
+
+// fanoutSubscribers mirrors a moderately busy room: one broadcast line
+// delivered to every subscriber's connection.
+const fanoutSubscribers = 1000
+
+// benchFanout drives fanoutSubscribers real net.Pipe connections end to end:
+// a sender Client writes one line per subscriber while a concurrent
+// goroutine per subscriber reads it back off with ReadLine. That's the same
+// encode+write+goroutine+read path the server's real per-client fan-out
+// takes, not just the marshaling cost of one write in isolation.
+func benchFanout(b *testing.B, proto string) {
+	senders := make([]*Client, fanoutSubscribers)
+	receivers := make([]*Client, fanoutSubscribers)
+	for i := range senders {
+		serverSide, subscriberSide := net.Pipe()
+		senders[i] = newClient(serverSide, proto)
+		receivers[i] = newClient(subscriberSide, proto)
+	}
+	defer func() {
+		for i := range senders {
+			senders[i].Close()
+			receivers[i].Close()
+		}
+	}()
+
+	line := "MSG #general hello from the benchmark"
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		wg.Add(2 * fanoutSubscribers)
+		for j := range senders {
+			j := j
+			go func() {
+				defer wg.Done()
+				if _, err := receivers[j].ReadLine(); err != nil {
+					b.Error(err)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				if err := senders[j].Send(line); err != nil {
+					b.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// BenchmarkFanoutText measures a 1000-subscriber text-protocol broadcast.
+func BenchmarkFanoutText(b *testing.B) { benchFanout(b, ProtoText) }
+
+// BenchmarkFanoutBinary measures a 1000-subscriber binary-protocol
+// broadcast.
+func BenchmarkFanoutBinary(b *testing.B) { benchFanout(b, ProtoBinary) }
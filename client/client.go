@@ -0,0 +1,107 @@
+// Package client is a minimal chat client used for integration tests and
+// benchmarking. It authenticates over SSH exactly like the real server
+// expects, and can speak either of the server's wire protocols.
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/Brij812/socket-chat-go/wire"
+)
+
+// Wire protocols a Client can speak. The server detects which one a
+// connection is using from its first byte, so a Client just picks one.
+const (
+	ProtoText   = "text"
+	ProtoBinary = "binary"
+)
+
+// Client is a connected chat session.
+type Client struct {
+	sshConn io.Closer
+	stream  io.ReadWriter
+	proto   string
+	scanner *bufio.Scanner
+}
+
+// Dial opens an SSH connection to addr, authenticates as username with
+// signer, and opens the "session" channel the server expects. proto selects
+// which wire format the session speaks (ProtoText or ProtoBinary).
+func Dial(addr, username string, signer ssh.Signer, proto string) (*Client, error) {
+	if proto != ProtoText && proto != ProtoBinary {
+		return nil, fmt.Errorf("client: unknown proto %q", proto)
+	}
+
+	config := &ssh.ClientConfig{
+		User:            username,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	conn, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+	channel, requests, err := conn.OpenChannel("session", nil)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("open channel: %w", err)
+	}
+	go ssh.DiscardRequests(requests)
+
+	c := newClient(channel, proto)
+	c.sshConn = conn
+	return c, nil
+}
+
+func newClient(stream io.ReadWriter, proto string) *Client {
+	c := &Client{stream: stream, proto: proto}
+	if proto == ProtoText {
+		c.scanner = bufio.NewScanner(stream)
+	}
+	return c
+}
+
+// Send writes line to the server, framing it per the session's wire
+// protocol.
+func (c *Client) Send(line string) error {
+	if c.proto == ProtoBinary {
+		return wire.WriteFrame(c.stream, wire.EncodeLine(line))
+	}
+	_, err := fmt.Fprintln(c.stream, line)
+	return err
+}
+
+// ReadLine blocks for the next line the server sends, decoding it per the
+// session's wire protocol.
+func (c *Client) ReadLine() (string, error) {
+	if c.proto == ProtoBinary {
+		frame, err := wire.ReadFrame(c.stream)
+		if err != nil {
+			return "", err
+		}
+		return frame.Line(), nil
+	}
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return strings.TrimRight(c.scanner.Text(), "\r"), nil
+}
+
+// Close tears down the underlying channel and SSH connection.
+func (c *Client) Close() error {
+	if closer, ok := c.stream.(io.Closer); ok {
+		closer.Close()
+	}
+	if c.sshConn != nil {
+		return c.sshConn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"io"
+	"testing"
+)
+
+// loopback is a minimal io.ReadWriter wired to one end of an io.Pipe pair,
+// letting tests drive both sides of a Client without a real network
+// connection or SSH handshake.
+type loopback struct {
+	r io.Reader
+	w io.Writer
+}
+
+func (l *loopback) Read(p []byte) (int, error)  { return l.r.Read(p) }
+func (l *loopback) Write(p []byte) (int, error) { return l.w.Write(p) }
+
+func newLoopbackPair() (a, b *loopback) {
+	ar, aw := io.Pipe()
+	br, bw := io.Pipe()
+	return &loopback{r: ar, w: bw}, &loopback{r: br, w: aw}
+}
+
+func TestSendReadLineText(t *testing.T) {
+	a, b := newLoopbackPair()
+	sender := newClient(a, ProtoText)
+	receiver := newClient(b, ProtoText)
+
+	go func() {
+		if err := sender.Send("MSG #general hi"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	line, err := receiver.ReadLine()
+	if err != nil {
+		t.Fatalf("ReadLine: %v", err)
+	}
+	if line != "MSG #general hi" {
+		t.Fatalf("got %q, want %q", line, "MSG #general hi")
+	}
+}
+
+func TestSendReadLineBinary(t *testing.T) {
+	a, b := newLoopbackPair()
+	sender := newClient(a, ProtoBinary)
+	receiver := newClient(b, ProtoBinary)
+
+	go func() {
+		if err := sender.Send("JOIN #general"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	line, err := receiver.ReadLine()
+	if err != nil {
+		t.Fatalf("ReadLine: %v", err)
+	}
+	if line != "JOIN #general" {
+		t.Fatalf("got %q, want %q", line, "JOIN #general")
+	}
+}
+
+func TestDialRejectsUnknownProto(t *testing.T) {
+	if _, err := Dial("127.0.0.1:0", "alice", nil, "carrier-pigeon"); err == nil {
+		t.Fatal("expected an error for an unknown protocol")
+	}
+}
@@ -0,0 +1,258 @@
+// Package history implements a bounded per-key ring buffer of recent chat
+// lines, used to replay recent context to a client joining a room (or via
+// the HISTORY command) and, optionally, to persist that history across
+// server restarts through a pluggable Store.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is one recorded chat line.
+type Entry struct {
+	Line string
+	At   time.Time
+}
+
+// Store persists history entries for a key so they survive a server
+// restart. Implementations need not order entries across keys, only
+// within one.
+type Store interface {
+	// Append records entry under key.
+	Append(key string, entry Entry) error
+	// Load returns every entry persisted for key, oldest first.
+	Load(key string) ([]Entry, error)
+}
+
+// Ring is a bounded, per-key ring buffer of history entries. The zero
+// value is not usable; construct one with NewRing.
+type Ring struct {
+	mu       sync.Mutex
+	capacity int
+	store    Store
+	entries  map[string][]Entry
+	loaded   map[string]bool // whether key has been hydrated from store yet
+}
+
+// NewRing returns a Ring that keeps the last capacity entries per key. A
+// nil store disables persistence; entries then live only in memory.
+func NewRing(capacity int, store Store) *Ring {
+	return &Ring{
+		capacity: capacity,
+		store:    store,
+		entries:  make(map[string][]Entry),
+		loaded:   make(map[string]bool),
+	}
+}
+
+// Append records line under key, evicting the oldest entry once the key is
+// at capacity.
+func (r *Ring) Append(key, line string) {
+	entry := Entry{Line: line, At: time.Now()}
+
+	r.mu.Lock()
+	r.ensureLoadedLocked(key)
+	buf := append(r.entries[key], entry)
+	if len(buf) > r.capacity {
+		buf = buf[len(buf)-r.capacity:]
+	}
+	r.entries[key] = buf
+	r.mu.Unlock()
+
+	if r.store != nil {
+		if err := r.store.Append(key, entry); err != nil {
+			log.Printf("[HISTORY] persist key=%s: %v", key, err)
+		}
+	}
+}
+
+// Last returns up to n of the most recent entries for key, oldest first. It
+// returns fewer than n if that many haven't been recorded yet.
+func (r *Ring) Last(key string, n int) []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ensureLoadedLocked(key)
+
+	buf := r.entries[key]
+	if n <= 0 {
+		return nil
+	}
+	if n > len(buf) {
+		n = len(buf)
+	}
+	out := make([]Entry, n)
+	copy(out, buf[len(buf)-n:])
+	return out
+}
+
+// ensureLoadedLocked hydrates key's in-memory buffer from the store the
+// first time it's touched, so a freshly restarted server can still replay
+// history recorded before it last stopped. Caller must hold r.mu.
+func (r *Ring) ensureLoadedLocked(key string) {
+	if r.loaded[key] {
+		return
+	}
+	r.loaded[key] = true
+	if r.store == nil {
+		return
+	}
+	entries, err := r.store.Load(key)
+	if err != nil {
+		log.Printf("[HISTORY] load key=%s: %v", key, err)
+		return
+	}
+	if len(entries) > r.capacity {
+		entries = entries[len(entries)-r.capacity:]
+	}
+	r.entries[key] = entries
+}
+
+// record is one line of a FileStore's on-disk JSON-lines format.
+type record struct {
+	Key  string    `json:"key"`
+	Line string    `json:"line"`
+	At   time.Time `json:"at"`
+}
+
+// FileStore is a Store backed by an append-only JSON-lines file. It
+// periodically compacts the file, dropping entries beyond keep per key, so
+// a long-running server's history file doesn't grow unbounded.
+type FileStore struct {
+	mu              sync.Mutex
+	path            string
+	keep            int
+	compactEvery    int
+	appendsUntilRun int
+}
+
+// NewFileStore returns a FileStore persisting to path, keeping at most keep
+// entries per key on compaction, and compacting every compactEvery
+// appends. compactEvery of 0 disables automatic compaction.
+func NewFileStore(path string, keep, compactEvery int) *FileStore {
+	return &FileStore{path: path, keep: keep, compactEvery: compactEvery, appendsUntilRun: compactEvery}
+}
+
+func (s *FileStore) Append(key string, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(record{Key: key, Line: entry.Line, At: entry.At})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	_, werr := f.Write(append(data, '\n'))
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr != nil {
+		return werr
+	}
+
+	if s.compactEvery <= 0 {
+		return nil
+	}
+	s.appendsUntilRun--
+	if s.appendsUntilRun > 0 {
+		return nil
+	}
+	s.appendsUntilRun = s.compactEvery
+	return s.compactLocked()
+}
+
+func (s *FileStore) Load(key string) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.readAllLocked()
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, rec := range records {
+		if rec.Key == key {
+			entries = append(entries, Entry{Line: rec.Line, At: rec.At})
+		}
+	}
+	return entries, nil
+}
+
+func (s *FileStore) readAllLocked() ([]record, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []record
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// compactLocked rewrites the store file, keeping only the last s.keep
+// entries per key. Caller must hold s.mu.
+func (s *FileStore) compactLocked() error {
+	records, err := s.readAllLocked()
+	if err != nil {
+		return err
+	}
+
+	perKey := make(map[string][]record)
+	var order []string
+	for _, rec := range records {
+		if _, ok := perKey[rec.Key]; !ok {
+			order = append(order, rec.Key)
+		}
+		perKey[rec.Key] = append(perKey[rec.Key], rec)
+	}
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	for _, key := range order {
+		recs := perKey[key]
+		if len(recs) > s.keep {
+			recs = recs[len(recs)-s.keep:]
+		}
+		for _, rec := range recs {
+			data, err := json.Marshal(rec)
+			if err != nil {
+				f.Close()
+				return err
+			}
+			if _, err := f.Write(append(data, '\n')); err != nil {
+				f.Close()
+				return err
+			}
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,140 @@
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRingEvictionOrder(t *testing.T) {
+	r := NewRing(3, nil)
+	for _, line := range []string{"a", "b", "c", "d"} {
+		r.Append("room:general", line)
+	}
+
+	got := r.Last("room:general", 10)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 entries after eviction, got %d", len(got))
+	}
+	want := []string{"b", "c", "d"}
+	for i, w := range want {
+		if got[i].Line != w {
+			t.Fatalf("entry %d = %q, want %q", i, got[i].Line, w)
+		}
+	}
+}
+
+func TestRingReplayOrder(t *testing.T) {
+	r := NewRing(10, nil)
+	for _, line := range []string{"one", "two", "three"} {
+		r.Append("room:general", line)
+	}
+
+	got := r.Last("room:general", 2)
+	if len(got) != 2 || got[0].Line != "two" || got[1].Line != "three" {
+		t.Fatalf("unexpected replay order: %+v", got)
+	}
+}
+
+func TestRingLastIsolatesKeys(t *testing.T) {
+	r := NewRing(10, nil)
+	r.Append("room:general", "hi")
+	r.Append("dm:alice:bob", "psst")
+
+	if got := r.Last("room:general", 10); len(got) != 1 || got[0].Line != "hi" {
+		t.Fatalf("room:general = %+v", got)
+	}
+	if got := r.Last("dm:alice:bob", 10); len(got) != 1 || got[0].Line != "psst" {
+		t.Fatalf("dm:alice:bob = %+v", got)
+	}
+}
+
+func TestRingConcurrentAppendAndReplay(t *testing.T) {
+	const capacity = 50
+	const writers = 10
+	const perWriter = 20
+	r := NewRing(capacity, nil)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				r.Append("room:general", fmt.Sprintf("w%d-%d", w, i))
+				r.Last("room:general", capacity)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	got := r.Last("room:general", capacity)
+	if len(got) != capacity {
+		t.Fatalf("expected the ring to settle at capacity %d, got %d", capacity, len(got))
+	}
+}
+
+func TestFileStoreAppendAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := NewFileStore(path, 10, 0)
+
+	for _, line := range []string{"a", "b", "c"} {
+		if err := store.Append("room:general", Entry{Line: line}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if err := store.Append("dm:alice:bob", Entry{Line: "hi"}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	got, err := store.Load("room:general")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(got) != 3 || got[0].Line != "a" || got[2].Line != "c" {
+		t.Fatalf("unexpected load order: %+v", got)
+	}
+
+	dm, err := store.Load("dm:alice:bob")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(dm) != 1 || dm[0].Line != "hi" {
+		t.Fatalf("unexpected dm history: %+v", dm)
+	}
+}
+
+func TestFileStoreCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := NewFileStore(path, 2, 3) // compact every 3rd append, keep last 2 per key
+
+	for _, line := range []string{"a", "b", "c", "d", "e", "f"} {
+		if err := store.Append("room:general", Entry{Line: line}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	got, err := store.Load("room:general")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(got) != 2 || got[0].Line != "e" || got[1].Line != "f" {
+		t.Fatalf("expected compaction to keep only the last 2 entries, got %+v", got)
+	}
+}
+
+func TestRingSurvivesRestartViaStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := NewFileStore(path, 5, 0)
+
+	first := NewRing(5, store)
+	first.Append("room:general", "before restart")
+
+	// Simulate a server restart: a brand new Ring over the same store.
+	second := NewRing(5, store)
+	got := second.Last("room:general", 5)
+	if len(got) != 1 || got[0].Line != "before restart" {
+		t.Fatalf("expected history to survive across Ring instances, got %+v", got)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConn is a minimal connStream that tracks whether Close was called.
+type fakeConn struct {
+	mu     sync.Mutex
+	closed bool
+}
+
+func (f *fakeConn) Read(p []byte) (int, error)  { return 0, nil }
+func (f *fakeConn) Write(p []byte) (int, error) { return len(p), nil }
+func (f *fakeConn) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+	return nil
+}
+func (f *fakeConn) RemoteAddr() net.Addr { return &net.TCPAddr{} }
+func (f *fakeConn) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func TestClientSendEvictsOnSustainedFullQueue(t *testing.T) {
+	conn := &fakeConn{}
+	client := &Client{username: "alice", conn: conn, out: make(chan string, 1)}
+	client.out <- "someone is listening to this one" // fill the only slot
+
+	for i := 0; i < maxDroppedBeforeEvict-1; i++ {
+		client.send("dropped")
+		if conn.isClosed() {
+			t.Fatalf("client evicted too early, after %d drops", i+1)
+		}
+	}
+	client.send("dropped")
+	if !conn.isClosed() {
+		t.Fatal("expected client to be evicted after sustained full queue")
+	}
+}
+
+func TestClientSendRecoversAfterDrain(t *testing.T) {
+	conn := &fakeConn{}
+	client := &Client{username: "alice", conn: conn, out: make(chan string, 1)}
+	client.out <- "blocking"
+
+	client.send("dropped once")
+	<-client.out // drain, making room again
+	client.send("delivered")
+
+	select {
+	case msg := <-client.out:
+		if msg != "delivered" {
+			t.Fatalf("unexpected message: %q", msg)
+		}
+	default:
+		t.Fatal("expected the message to be delivered once the queue drained")
+	}
+	if conn.isClosed() {
+		t.Fatal("client should not be evicted once sends are succeeding again")
+	}
+}
+
+func TestKeepaliveLoopEvictsOnMissedPong(t *testing.T) {
+	conn := &fakeConn{}
+	client := &Client{username: "alice", conn: conn, out: make(chan string, 8)}
+	done := make(chan struct{})
+	defer close(done)
+
+	go keepaliveLoop(client, 10*time.Millisecond, done)
+
+	select {
+	case msg := <-client.out:
+		if len(msg) < 5 || msg[:5] != "PING " {
+			t.Fatalf("expected a PING, got %q", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the first PING")
+	}
+
+	// Never reply with PONG: the next tick should find the ping still
+	// outstanding and evict the client.
+	deadline := time.After(time.Second)
+	for !conn.isClosed() {
+		select {
+		case <-deadline:
+			t.Fatal("expected client to be evicted after a missed PONG")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+func TestKeepaliveLoopSurvivesAnsweredPong(t *testing.T) {
+	conn := &fakeConn{}
+	client := &Client{username: "alice", conn: conn, out: make(chan string, 8)}
+	done := make(chan struct{})
+	defer close(done)
+
+	go keepaliveLoop(client, 10*time.Millisecond, done)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-client.out:
+			client.keepaliveMu.Lock()
+			client.pingNonce = ""
+			client.keepaliveMu.Unlock()
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for a PING")
+		}
+	}
+
+	if conn.isClosed() {
+		t.Fatal("a client that keeps answering PINGs should never be evicted")
+	}
+}
@@ -4,30 +4,150 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/Brij812/socket-chat-go/auth"
+	"github.com/Brij812/socket-chat-go/history"
+	"github.com/Brij812/socket-chat-go/wire"
+)
+
+// Wire protocol a connection speaks. Text is the original newline-delimited
+// format; binary uses the wire package's length-prefixed frames.
+const (
+	protoText   = "text"
+	protoBinary = "binary"
 )
 
+// connStream is the minimal connection surface handleConn needs. A plain
+// net.Conn satisfies it directly; an SSH channel is adapted to it by
+// sshChannelConn (see ssh.go).
+type connStream interface {
+	io.Reader
+	io.Writer
+	io.Closer
+	RemoteAddr() net.Addr
+}
+
 // Client represents a connected user
 type Client struct {
-	username string
-	conn     net.Conn
-	out      chan string // outbound messages
+	username    string
+	fingerprint string // SHA-256 pubkey fingerprint, set for SSH connections
+	conn        connStream
+	proto       string      // protoText or protoBinary
+	out         chan string // outbound messages
+	dropped     int32       // consecutive sends that found out full, atomic
+
+	// writeMu serializes every write to conn. A binary wire.Frame is two
+	// conn.Write calls (length, then body); without this, clientWriter
+	// (draining out) and writeSafe (direct replies like PONG/OK/ERR) could
+	// interleave their frames on the wire and desync the stream.
+	writeMu sync.Mutex
+
+	// rooms the client currently occupies. Only ever mutated while
+	// holding Hub.mu, so it needs no lock of its own.
+	rooms map[string]struct{}
+
+	keepaliveMu sync.Mutex
+	lastSeen    time.Time // last time any line was received from the client
+	pingNonce   string    // nonce of the outstanding keepalive PING, "" if none
+}
+
+const maxDroppedBeforeEvict = 5
+
+// send delivers line to the client without blocking. If out stays full for
+// maxDroppedBeforeEvict consecutive attempts, the client is considered
+// wedged and its connection is closed so the sending goroutine can't be
+// stalled indefinitely by one slow reader.
+func (c *Client) send(line string) {
+	select {
+	case c.out <- line:
+		atomic.StoreInt32(&c.dropped, 0)
+	default:
+		if atomic.AddInt32(&c.dropped, 1) >= maxDroppedBeforeEvict {
+			log.Printf("[EVICT] user=%s outbound queue full, disconnecting", c.username)
+			c.conn.Close()
+		}
+	}
+}
+
+func (c *Client) touch() {
+	c.keepaliveMu.Lock()
+	c.lastSeen = time.Now()
+	c.keepaliveMu.Unlock()
+}
+
+func (c *Client) idleDuration() time.Duration {
+	c.keepaliveMu.Lock()
+	defer c.keepaliveMu.Unlock()
+	if c.lastSeen.IsZero() {
+		return 0
+	}
+	return time.Since(c.lastSeen)
 }
 
-// Hub keeps track of active users and broadcasting
+// Hub keeps track of active users, rooms, and broadcasting
 type Hub struct {
-	mu    sync.RWMutex
-	users map[string]*Client // username -> client
+	mu      sync.RWMutex
+	users   map[string]*Client            // username -> client
+	rooms   map[string]map[string]*Client // room -> username -> client
+	history *history.Ring                 // nil if history is disabled
 }
 
 func NewHub() *Hub {
-	return &Hub{users: make(map[string]*Client)}
+	return &Hub{
+		users: make(map[string]*Client),
+		rooms: make(map[string]map[string]*Client),
+	}
+}
+
+// enableHistory turns on bounded message history: the last capacity
+// messages per room and per DM pair are kept for replay, optionally backed
+// by store so they survive a restart.
+func (h *Hub) enableHistory(capacity int, store history.Store) {
+	h.history = history.NewRing(capacity, store)
+}
+
+// recordHistory appends line to key's history, if history is enabled.
+func (h *Hub) recordHistory(key, line string) {
+	if h.history != nil {
+		h.history.Append(key, line)
+	}
+}
+
+// replayHistory returns up to n of the most recent lines recorded under
+// key, oldest first. It returns nil if history is disabled.
+func (h *Hub) replayHistory(key string, n int) []string {
+	if h.history == nil {
+		return nil
+	}
+	entries := h.history.Last(key, n)
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = e.Line
+	}
+	return lines
+}
+
+// roomHistoryKey and dmHistoryKey namespace history.Ring keys by scope, so
+// a room name and a username pair can never collide.
+func roomHistoryKey(room string) string {
+	return "room:" + room
+}
+
+func dmHistoryKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return "dm:" + a + ":" + b
 }
 
 func (h *Hub) addUser(c *Client) error {
@@ -40,9 +160,20 @@ func (h *Hub) addUser(c *Client) error {
 	return nil
 }
 
+// removeUser drops the client from the hub and every room it occupied.
 func (h *Hub) removeUser(username string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	c, ok := h.users[username]
+	if !ok {
+		return
+	}
+	for room := range c.rooms {
+		delete(h.rooms[room], username)
+		if len(h.rooms[room]) == 0 {
+			delete(h.rooms, room)
+		}
+	}
 	delete(h.users, username)
 }
 
@@ -53,15 +184,114 @@ func (h *Hub) broadcast(sender, line string) {
 		if sender != "" && c.username == sender {
 			continue
 		}
-		select {
-		case c.out <- line:
-		default:
+		c.send(line)
+	}
+}
+
+// joinRoom adds c to room, creating it if necessary. It is a no-op if the
+// client is already present. Returns false if already joined.
+func (h *Hub) joinRoom(c *Client, room string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := c.rooms[room]; ok {
+		return false
+	}
+	if h.rooms[room] == nil {
+		h.rooms[room] = make(map[string]*Client)
+	}
+	h.rooms[room][c.username] = c
+	if c.rooms == nil {
+		c.rooms = make(map[string]struct{})
+	}
+	c.rooms[room] = struct{}{}
+	return true
+}
+
+// leaveRoom removes c from room. Returns false if the client wasn't in it.
+func (h *Hub) leaveRoom(c *Client, room string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := c.rooms[room]; !ok {
+		return false
+	}
+	delete(c.rooms, room)
+	delete(h.rooms[room], c.username)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+	}
+	return true
+}
+
+// clientInRoom reports whether c currently occupies room.
+func (h *Hub) clientInRoom(c *Client, room string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := c.rooms[room]
+	return ok
+}
+
+// roomList returns the names of all rooms that currently have members.
+func (h *Hub) roomList() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	names := make([]string, 0, len(h.rooms))
+	for name := range h.rooms {
+		names = append(names, name)
+	}
+	return names
+}
+
+// roomMembers returns the usernames present in room.
+func (h *Hub) roomMembers(room string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	members := make([]string, 0, len(h.rooms[room]))
+	for name := range h.rooms[room] {
+		members = append(members, name)
+	}
+	return members
+}
+
+// broadcastRoom delivers line to every member of room except sender.
+func (h *Hub) broadcastRoom(room, sender, line string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for username, c := range h.rooms[room] {
+		if sender != "" && username == sender {
+			continue
 		}
+		c.send(line)
+	}
+}
+
+// Config bundles server-wide settings and shared services that get
+// threaded into every connection handler.
+type Config struct {
+	auth        *auth.Auth
+	admins      map[string]struct{} // usernames (or, with SSH, key fingerprints) allowed to run admin commands
+	keepalive   time.Duration       // interval between server-initiated PINGs; a missed PONG by the next tick evicts the client
+	historySize int                 // messages kept per room/DM and replayed to joiners; 0 disables history
+}
+
+func (cfg *Config) isAdmin(c *Client) bool {
+	if _, ok := cfg.admins[c.username]; ok {
+		return true
+	}
+	if c.fingerprint == "" {
+		return false
 	}
+	_, ok := cfg.admins[c.fingerprint]
+	return ok
 }
 
 func main() {
 	var flagPort = flag.Int("port", 4000, "Port to listen on")
+	var flagAdmin = flag.String("admin", "", "comma-separated usernames or key fingerprints allowed to run admin commands")
+	var flagBanFile = flag.String("banfile", "bans.json", "path to persist the ban list (empty disables persistence)")
+	var flagHostKey = flag.String("i", "host_key", "path to the SSH host private key (auto-generated if missing)")
+	var flagKeepalive = flag.Duration("keepalive", 30*time.Second, "interval between server-initiated PINGs; a missed PONG evicts the client")
+	var flagHistory = flag.Int("history", 20, "messages kept per room/DM and replayed to joiners (0 disables history)")
+	var flagHistoryFile = flag.String("historyfile", "history.jsonl", "path to persist message history (empty disables persistence)")
 	flag.Parse()
 
 	port := *flagPort
@@ -77,9 +307,22 @@ func main() {
 		log.Fatalf("failed to listen on %s: %v", addr, err)
 	}
 	defer ln.Close()
-	log.Printf("chat server listening on %s", addr)
+	log.Printf("chat server (ssh, text+binary auto-detected) listening on %s", addr)
 
 	hub := NewHub()
+	if *flagHistory > 0 {
+		var store history.Store
+		if *flagHistoryFile != "" {
+			store = history.NewFileStore(*flagHistoryFile, *flagHistory, 200)
+		}
+		hub.enableHistory(*flagHistory, store)
+	}
+	cfg := &Config{
+		auth:        auth.New(*flagBanFile),
+		admins:      parseAdmins(*flagAdmin),
+		keepalive:   *flagKeepalive,
+		historySize: *flagHistory,
+	}
 
 	// Graceful shutdown
 	sig := make(chan os.Signal, 1)
@@ -91,136 +334,465 @@ func main() {
 		os.Exit(0)
 	}()
 
-	for {
-		conn, err := ln.Accept()
-		if err != nil {
-			log.Printf("accept error: %v", err)
-			continue
+	if err := serveSSH(ln, *flagHostKey, hub, cfg); err != nil {
+		log.Fatalf("ssh server failed: %v", err)
+	}
+}
+
+func parseAdmins(flagValue string) map[string]struct{} {
+	admins := make(map[string]struct{})
+	for _, name := range strings.Split(flagValue, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			admins[name] = struct{}{}
 		}
-		go handleConn(hub, conn)
 	}
+	return admins
+}
+
+// sshIdentity carries the username and key fingerprint already established
+// by an SSH handshake, bypassing the LOGIN exchange used by other
+// transports.
+type sshIdentity struct {
+	username    string
+	fingerprint string
 }
 
-func handleConn(hub *Hub, conn net.Conn) {
+// handleConn drives a single client's session. identity is nil for
+// transports that authenticate via the LOGIN command; it is set for SSH
+// connections, whose username and fingerprint come from the handshake.
+//
+// The wire protocol is detected per-connection (see detectProto), not fixed
+// server-wide, so text and binary clients can share the same listener.
+func handleConn(hub *Hub, cfg *Config, conn connStream, identity *sshIdentity) {
 	defer conn.Close()
 	log.Printf("[CONNECT] new connection from %s", conn.RemoteAddr())
 
-	reader := bufio.NewScanner(conn)
-	buf := make([]byte, 0, 64*1024)
-	reader.Buffer(buf, 64*1024)
+	reader := bufio.NewReaderSize(conn, 64*1024)
+	proto, err := detectProto(reader)
+	if err != nil {
+		return
+	}
 
-	if !reader.Scan() {
+	if host, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil && cfg.auth.IsBanned(auth.IP, host) {
+		writeRaw(conn, proto, "ERR banned")
+		log.Printf("[BANNED] rejected connection from %s", conn.RemoteAddr())
 		return
 	}
-	line := cleanLine(reader.Text())
-	if !strings.HasPrefix(strings.ToUpper(line), "LOGIN ") {
-		writeSafe(conn, "ERR expected 'LOGIN <username>'")
+	if identity != nil && identity.fingerprint != "" && cfg.auth.IsBanned(auth.Key, identity.fingerprint) {
+		writeRaw(conn, proto, "ERR banned")
+		log.Printf("[BANNED] rejected fingerprint=%s from %s", identity.fingerprint, conn.RemoteAddr())
 		return
 	}
-	username := strings.TrimSpace(line[len("LOGIN "):])
-	if username == "" || strings.Contains(username, " ") {
-		writeSafe(conn, "ERR invalid-username")
+
+	var scanner *bufio.Scanner
+	if proto != protoBinary {
+		scanner = bufio.NewScanner(reader)
+		buf := make([]byte, 0, 64*1024)
+		scanner.Buffer(buf, 64*1024)
+	}
+
+	var username, fingerprint string
+	if identity != nil {
+		username = identity.username
+		fingerprint = identity.fingerprint
+	} else {
+		raw, ok := readOneLine(reader, proto, scanner)
+		if !ok {
+			return
+		}
+		line := cleanLine(raw)
+		if !strings.HasPrefix(strings.ToUpper(line), "LOGIN ") {
+			writeRaw(conn, proto, "ERR expected 'LOGIN <username>'")
+			return
+		}
+		username = strings.TrimSpace(line[len("LOGIN "):])
+		if username == "" || strings.Contains(username, " ") {
+			writeRaw(conn, proto, "ERR invalid-username")
+			return
+		}
+	}
+	if cfg.auth.IsBanned(auth.Username, username) {
+		writeRaw(conn, proto, "ERR banned")
+		log.Printf("[BANNED] rejected username=%s from %s", username, conn.RemoteAddr())
 		return
 	}
 
 	client := &Client{
-		username: username,
-		conn:     conn,
-		out:      make(chan string, 32),
+		username:    username,
+		fingerprint: fingerprint,
+		conn:        conn,
+		proto:       proto,
+		out:         make(chan string, 32),
 	}
 	if err := hub.addUser(client); err != nil {
-		writeSafe(conn, "ERR username-taken")
+		writeRaw(conn, proto, "ERR username-taken")
 		return
 	}
-	log.Printf("[LOGIN] user=%s", username)
+	log.Printf("[LOGIN] user=%s fingerprint=%s proto=%s", username, fingerprint, proto)
 
+	done := make(chan struct{})
 	defer func() {
+		close(done)
 		hub.removeUser(client.username)
 		hub.broadcast("", fmt.Sprintf("INFO %s disconnected", client.username))
 		log.Printf("[DISCONNECT] %s disconnected", client.username)
 	}()
 
-	writeSafe(conn, "OK")
-	done := make(chan struct{})
+	writeSafe(client, "OK")
 	go clientWriter(client, done)
+	go keepaliveLoop(client, cfg.keepalive, done)
 
-	idleTimer := time.NewTimer(60 * time.Second)
-	resetTimer := func(d time.Duration) {
-		if !idleTimer.Stop() {
-			select {
-			case <-idleTimer.C:
-			default:
-			}
+	// A wedged or dead peer is detected entirely by keepaliveLoop's
+	// PING/PONG exchange (missed PONG) and send's outbound-queue eviction,
+	// not by read/write deadlines: the only transport is now SSH, whose
+	// channels multiplex over one TCP connection and can't support a
+	// per-channel deadline.
+	for {
+		raw, ok := readOneLine(reader, proto, scanner)
+		if !ok {
+			return
 		}
-		idleTimer.Reset(d)
-	}
-	go func() {
-		<-idleTimer.C
-		writeSafe(conn, "INFO disconnected due to inactivity")
-		conn.Close()
-	}()
-
-	for reader.Scan() {
-		line := cleanLine(reader.Text())
+		line := cleanLine(raw)
 		if line == "" {
 			continue
 		}
-		resetTimer(60 * time.Second)
+		client.touch()
+		dispatchLine(hub, cfg, client, line)
+	}
+}
 
-		upper := strings.ToUpper(line)
-		switch {
-		case strings.HasPrefix(upper, "MSG "):
-			text := strings.TrimSpace(line[len("MSG "):])
+// detectProto peeks at r's first byte to decide whether this connection
+// speaks the binary wire protocol or the text protocol, so a single
+// listener can serve both kinds of client at once. A wire.Frame's length
+// prefix is capped well under 16 MiB, so its leading
+// byte is always 0x00; no text-protocol command line can start with a NUL
+// byte, so the two are told apart unambiguously from the first byte alone.
+func detectProto(r *bufio.Reader) (string, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+	if b[0] == 0 {
+		return protoBinary, nil
+	}
+	return protoText, nil
+}
+
+// readOneLine reads the next command line from r: a length-prefixed
+// wire.Frame for the binary protocol, or the next scanned line for text.
+func readOneLine(r io.Reader, proto string, scanner *bufio.Scanner) (string, bool) {
+	if proto == protoBinary {
+		frame, err := wire.ReadFrame(r)
+		if err != nil {
+			return "", false
+		}
+		return frame.Line(), true
+	}
+	if !scanner.Scan() {
+		return "", false
+	}
+	return scanner.Text(), true
+}
+
+// dispatchLine executes one already-cleaned, non-empty command line for
+// client. Both wire protocols decode to the same line format, so this is
+// the single place command behavior lives regardless of transport.
+func dispatchLine(hub *Hub, cfg *Config, client *Client, line string) {
+	upper := strings.ToUpper(line)
+	switch {
+	case strings.HasPrefix(upper, "MSG "):
+		rest := strings.TrimSpace(line[len("MSG "):])
+		if rest == "" {
+			return
+		}
+		if room, ok := parseRoomArg(rest); ok {
+			text := strings.TrimSpace(rest[len(room)+1:])
 			if text == "" {
-				continue
+				return
 			}
-			msg := fmt.Sprintf("MSG %s %s", client.username, text)
-			hub.broadcast(client.username, msg)
-			log.Printf("[MSG] from=%s text=%q", client.username, text)
-
-		case upper == "WHO":
-			hub.mu.RLock()
-			for _, c := range hub.users {
-				writeSafe(conn, fmt.Sprintf("USER %s", c.username))
+			if !hub.clientInRoom(client, room) {
+				writeSafe(client, fmt.Sprintf("ERR not-in-room %s", room))
+				return
 			}
-			hub.mu.RUnlock()
+			msg := fmt.Sprintf("MSG %s %s %s", room, client.username, text)
+			hub.broadcastRoom(room, client.username, msg)
+			hub.recordHistory(roomHistoryKey(room), msg)
+			log.Printf("[MSG] room=%s from=%s text=%q", room, client.username, text)
+			return
+		}
+		msg := fmt.Sprintf("MSG %s %s", client.username, rest)
+		hub.broadcast(client.username, msg)
+		log.Printf("[MSG] from=%s text=%q", client.username, rest)
+
+	case strings.HasPrefix(upper, "JOIN "):
+		room, ok := parseRoomArg(strings.TrimSpace(line[len("JOIN "):]))
+		if !ok {
+			writeSafe(client, "ERR usage: JOIN #room")
+			return
+		}
+		if hub.joinRoom(client, room) {
+			hub.broadcastRoom(room, "", fmt.Sprintf("INFO %s joined %s", client.username, room))
+			log.Printf("[JOIN] user=%s room=%s", client.username, room)
+			for _, line := range hub.replayHistory(roomHistoryKey(room), cfg.historySize) {
+				writeSafe(client, line)
+			}
+		}
+		writeSafe(client, fmt.Sprintf("OK joined %s", room))
 
-		case upper == "PING":
-			writeSafe(conn, "PONG")
+	case strings.HasPrefix(upper, "LEAVE "):
+		room, ok := parseRoomArg(strings.TrimSpace(line[len("LEAVE "):]))
+		if !ok {
+			writeSafe(client, "ERR usage: LEAVE #room")
+			return
+		}
+		if hub.leaveRoom(client, room) {
+			hub.broadcastRoom(room, "", fmt.Sprintf("INFO %s left %s", client.username, room))
+			log.Printf("[LEAVE] user=%s room=%s", client.username, room)
+		}
+		writeSafe(client, fmt.Sprintf("OK left %s", room))
 
-		case strings.HasPrefix(upper, "DM "):
-			parts := strings.SplitN(line, " ", 3)
-			if len(parts) < 3 {
-				writeSafe(conn, "ERR usage: DM <username> <text>")
-				continue
-			}
-			targetName := strings.TrimSpace(parts[1])
-			messageText := strings.TrimSpace(parts[2])
+	case upper == "ROOMS":
+		for _, room := range hub.roomList() {
+			writeSafe(client, fmt.Sprintf("ROOM %s", room))
+		}
 
-			if targetName == "" || messageText == "" {
-				writeSafe(conn, "ERR usage: DM <username> <text>")
-				continue
-			}
+	case strings.HasPrefix(upper, "WHO "):
+		room, ok := parseRoomArg(strings.TrimSpace(line[len("WHO "):]))
+		if !ok {
+			writeSafe(client, "ERR usage: WHO #room")
+			return
+		}
+		hub.mu.RLock()
+		for username, c := range hub.rooms[room] {
+			writeSafe(client, fmt.Sprintf("USER %s %s %s idle=%s", room, username, displayFingerprint(c), c.idleDuration().Round(time.Second)))
+		}
+		hub.mu.RUnlock()
+
+	case upper == "WHO":
+		hub.mu.RLock()
+		for _, c := range hub.users {
+			writeSafe(client, fmt.Sprintf("USER %s %s idle=%s", c.username, displayFingerprint(c), c.idleDuration().Round(time.Second)))
+		}
+		hub.mu.RUnlock()
 
-			hub.mu.RLock()
-			target, ok := hub.users[targetName]
-			hub.mu.RUnlock()
+	case upper == "PING":
+		writeSafe(client, "PONG")
 
-			if !ok {
-				writeSafe(conn, "ERR user-not-found")
-				continue
-			}
+	case strings.HasPrefix(upper, "PONG "):
+		nonce := strings.TrimSpace(line[len("PONG "):])
+		client.keepaliveMu.Lock()
+		if nonce != "" && nonce == client.pingNonce {
+			client.pingNonce = ""
+		}
+		client.keepaliveMu.Unlock()
+
+	case strings.HasPrefix(upper, "DM "):
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) < 3 {
+			writeSafe(client, "ERR usage: DM <username> <text>")
+			return
+		}
+		targetName := strings.TrimSpace(parts[1])
+		messageText := strings.TrimSpace(parts[2])
 
-			// Send the DM only to the target
-			target.out <- fmt.Sprintf("DM %s %s", client.username, messageText)
+		if targetName == "" || messageText == "" {
+			writeSafe(client, "ERR usage: DM <username> <text>")
+			return
+		}
 
-			// Log the DM server-side but don't show it to the sender
-			log.Printf("[DM] from=%s to=%s text=%q", client.username, targetName, messageText)
+		hub.mu.RLock()
+		target, ok := hub.users[targetName]
+		hub.mu.RUnlock()
 
-		default:
-			writeSafe(conn, "ERR unknown-cmd")
+		if !ok {
+			writeSafe(client, "ERR user-not-found")
+			return
 		}
+
+		// Send the DM only to the target
+		dmLine := fmt.Sprintf("DM %s %s", client.username, messageText)
+		target.send(dmLine)
+		hub.recordHistory(dmHistoryKey(client.username, targetName), dmLine)
+
+		// Log the DM server-side but don't show it to the sender
+		log.Printf("[DM] from=%s to=%s text=%q", client.username, targetName, messageText)
+
+	case upper == "HISTORY" || strings.HasPrefix(upper, "HISTORY "):
+		handleHistory(hub, cfg, client, line)
+
+	case strings.HasPrefix(upper, "BAN "):
+		handleBan(cfg, client, line)
+
+	case strings.HasPrefix(upper, "UNBAN "):
+		handleUnban(cfg, client, line)
+
+	case strings.HasPrefix(upper, "KICK "):
+		handleKick(hub, cfg, client, line)
+
+	case upper == "BANLIST":
+		handleBanlist(cfg, client)
+
+	default:
+		writeSafe(client, "ERR unknown-cmd")
+	}
+}
+
+// handleHistory replays recorded lines for a room ("#room") or DM peer
+// ("@user") to client, the same way JOIN auto-replays a room's history.
+// The optional trailing n overrides the server's default history depth.
+func handleHistory(hub *Hub, cfg *Config, client *Client, line string) {
+	args := strings.Fields(line)
+	if len(args) < 2 {
+		writeSafe(client, "ERR usage: HISTORY #room|@user [n]")
+		return
+	}
+
+	var key string
+	switch target := args[1]; {
+	case strings.HasPrefix(target, "#") && len(target) > 1:
+		key = roomHistoryKey(target[1:])
+	case strings.HasPrefix(target, "@") && len(target) > 1:
+		key = dmHistoryKey(client.username, target[1:])
+	default:
+		writeSafe(client, "ERR usage: HISTORY #room|@user [n]")
+		return
+	}
+
+	n := cfg.historySize
+	if len(args) >= 3 {
+		if v, err := strconv.Atoi(args[2]); err == nil && v > 0 {
+			n = v
+		}
+	}
+
+	for _, l := range hub.replayHistory(key, n) {
+		writeSafe(client, l)
+	}
+	writeSafe(client, "OK history")
+}
+
+// requireAdmin writes an error and returns false if client isn't permitted
+// to run operator commands.
+func requireAdmin(cfg *Config, client *Client) bool {
+	if !cfg.isAdmin(client) {
+		writeSafe(client, "ERR not-admin")
+		return false
+	}
+	return true
+}
+
+func handleBan(cfg *Config, client *Client, line string) {
+	if !requireAdmin(cfg, client) {
+		return
+	}
+	parts := strings.Fields(line)
+	if len(parts) != 4 {
+		writeSafe(client, "ERR usage: BAN name|ip|key <value> <duration>")
+		return
+	}
+	kind, ok := parseBanKind(parts[1])
+	if !ok {
+		writeSafe(client, "ERR usage: BAN name|ip|key <value> <duration>")
+		return
+	}
+	value := parts[2]
+	d, err := parseBanDuration(parts[3])
+	if err != nil {
+		writeSafe(client, fmt.Sprintf("ERR invalid-duration %v", err))
+		return
+	}
+	if err := cfg.auth.Ban(kind, value, d); err != nil {
+		writeSafe(client, fmt.Sprintf("ERR %v", err))
+		return
 	}
+	log.Printf("[BAN] admin=%s kind=%s value=%s duration=%s", client.username, kind, value, parts[3])
+	writeSafe(client, fmt.Sprintf("OK banned %s %s", kind, value))
+}
+
+func handleUnban(cfg *Config, client *Client, line string) {
+	if !requireAdmin(cfg, client) {
+		return
+	}
+	parts := strings.Fields(line)
+	if len(parts) != 3 {
+		writeSafe(client, "ERR usage: UNBAN name|ip|key <value>")
+		return
+	}
+	kind, ok := parseBanKind(parts[1])
+	if !ok {
+		writeSafe(client, "ERR usage: UNBAN name|ip|key <value>")
+		return
+	}
+	removed, err := cfg.auth.Unban(kind, parts[2])
+	if err != nil {
+		writeSafe(client, fmt.Sprintf("ERR %v", err))
+		return
+	}
+	if !removed {
+		writeSafe(client, "ERR not-banned")
+		return
+	}
+	log.Printf("[UNBAN] admin=%s kind=%s value=%s", client.username, kind, parts[2])
+	writeSafe(client, fmt.Sprintf("OK unbanned %s %s", kind, parts[2]))
+}
+
+func handleKick(hub *Hub, cfg *Config, client *Client, line string) {
+	if !requireAdmin(cfg, client) {
+		return
+	}
+	parts := strings.Fields(line)
+	if len(parts) != 2 {
+		writeSafe(client, "ERR usage: KICK <user>")
+		return
+	}
+	targetName := parts[1]
+	hub.mu.RLock()
+	target, ok := hub.users[targetName]
+	hub.mu.RUnlock()
+	if !ok {
+		writeSafe(client, "ERR user-not-found")
+		return
+	}
+	writeSafe(target, "INFO kicked by admin")
+	target.conn.Close()
+	log.Printf("[KICK] admin=%s target=%s", client.username, targetName)
+	writeSafe(client, fmt.Sprintf("OK kicked %s", targetName))
+}
+
+func handleBanlist(cfg *Config, client *Client) {
+	if !requireAdmin(cfg, client) {
+		return
+	}
+	for _, entry := range cfg.auth.List() {
+		if entry.ExpiresAt.IsZero() {
+			writeSafe(client, fmt.Sprintf("BANNED %s %s permanent", entry.Kind, entry.Value))
+			continue
+		}
+		writeSafe(client, fmt.Sprintf("BANNED %s %s %s", entry.Kind, entry.Value, entry.ExpiresAt.Format(time.RFC3339)))
+	}
+}
+
+func parseBanKind(s string) (auth.Kind, bool) {
+	switch strings.ToLower(s) {
+	case "name", "username":
+		return auth.Username, true
+	case "ip":
+		return auth.IP, true
+	case "key":
+		return auth.Key, true
+	default:
+		return "", false
+	}
+}
+
+// parseBanDuration parses a BAN command's duration argument. "0" and
+// "permanent" both mean the ban never expires.
+func parseBanDuration(s string) (time.Duration, error) {
+	if s == "0" || strings.EqualFold(s, "permanent") {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
 }
 
 func clientWriter(c *Client, done chan struct{}) {
@@ -231,24 +803,91 @@ func clientWriter(c *Client, done chan struct{}) {
 			if !ok {
 				return
 			}
+			c.writeMu.Lock()
+			if c.proto == protoBinary {
+				err := wire.WriteFrame(c.conn, wire.EncodeLine(line))
+				c.writeMu.Unlock()
+				if err != nil {
+					log.Printf("[ERROR] write to %s failed: %v", c.username, err)
+					return
+				}
+				continue
+			}
 			if !strings.HasSuffix(line, "\n") {
 				line += "\n"
 			}
-			if _, err := w.WriteString(line); err != nil {
-				log.Printf("[ERROR] write to %s failed: %v", c.username, err)
+			_, err := w.WriteString(line)
+			if err == nil {
+				err = w.Flush()
+			}
+			c.writeMu.Unlock()
+			if err != nil {
+				log.Printf("[ERROR] write/flush to %s failed: %v", c.username, err)
 				return
 			}
-			if err := w.Flush(); err != nil {
-				log.Printf("[ERROR] flush to %s failed: %v", c.username, err)
+		case <-done:
+			return
+		}
+	}
+}
+
+// keepaliveLoop sends the client a PING every interval and expects a
+// matching PONG before the following tick. A client that keeps responding
+// is never evicted no matter how idle it looks; a client that misses a
+// PONG is truly unresponsive and gets disconnected.
+func keepaliveLoop(client *Client, interval time.Duration, done <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			client.keepaliveMu.Lock()
+			missed := client.pingNonce != ""
+			nonce := nextPingNonce()
+			client.pingNonce = nonce
+			client.keepaliveMu.Unlock()
+
+			if missed {
+				log.Printf("[KEEPALIVE] user=%s missed PONG, disconnecting", client.username)
+				client.conn.Close()
 				return
 			}
+			client.send("PING " + nonce)
 		case <-done:
 			return
 		}
 	}
 }
 
-func writeSafe(conn net.Conn, msg string) {
+var pingSeq uint64
+
+func nextPingNonce() string {
+	return strconv.FormatUint(atomic.AddUint64(&pingSeq, 1), 36)
+}
+
+// writeSafe sends msg directly to client, honoring its wire protocol. It is
+// used for pre-queue responses (e.g. the initial "OK") that must not be
+// reordered against clientWriter's buffered output. It takes c.writeMu so its
+// write can't interleave with clientWriter's concurrent writes to the same
+// conn.
+func writeSafe(c *Client, msg string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	writeRaw(c.conn, c.proto, msg)
+}
+
+// writeRaw is writeSafe's transport-only variant, for call sites (pre-login
+// rejections) that don't have a *Client yet.
+func writeRaw(conn connStream, proto string, msg string) {
+	if proto == protoBinary {
+		if err := wire.WriteFrame(conn, wire.EncodeLine(msg)); err != nil {
+			log.Printf("[ERROR] write failed to %v: %v", conn.RemoteAddr(), err)
+		}
+		return
+	}
 	if _, err := fmt.Fprintln(conn, msg); err != nil {
 		log.Printf("[ERROR] write failed to %v: %v", conn.RemoteAddr(), err)
 	}
@@ -269,6 +908,30 @@ func cleanLine(s string) string {
 	return cmd + " " + rest
 }
 
+// displayFingerprint returns c's key fingerprint for WHO output, or "-" for
+// clients that didn't authenticate over SSH.
+func displayFingerprint(c *Client) string {
+	if c.fingerprint == "" {
+		return "-"
+	}
+	return c.fingerprint
+}
+
+// parseRoomArg extracts a leading "#room" token from s, returning the room
+// name without its '#' prefix. ok is false if s doesn't start with a room
+// reference.
+func parseRoomArg(s string) (string, bool) {
+	if s == "" || s[0] != '#' {
+		return "", false
+	}
+	token := strings.SplitN(s, " ", 2)[0]
+	name := token[1:]
+	if name == "" {
+		return "", false
+	}
+	return name, true
+}
+
 func parsePort(s string) (int, error) {
 	var p int
 	_, err := fmt.Sscanf(strings.TrimSpace(s), "%d", &p)
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Brij812/socket-chat-go/wire"
+)
+
+// testConn is a connStream that captures whatever is written to it, so
+// tests can assert on direct (non-broadcast) responses like replayed
+// history or command acknowledgements.
+type testConn struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *testConn) Read(p []byte) (int, error) { return 0, io.EOF }
+func (c *testConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+func (c *testConn) Close() error         { return nil }
+func (c *testConn) RemoteAddr() net.Addr { return &net.TCPAddr{} }
+
+// lines returns every complete line written so far.
+func (c *testConn) lines() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	text := strings.TrimRight(c.buf.String(), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+func newTestClient(username string) *Client {
+	return &Client{
+		username: username,
+		conn:     &testConn{},
+		proto:    protoText,
+		out:      make(chan string, 8),
+	}
+}
+
+func TestJoinLeaveLifecycle(t *testing.T) {
+	hub := NewHub()
+	alice := newTestClient("alice")
+
+	if !hub.joinRoom(alice, "general") {
+		t.Fatal("expected first join to succeed")
+	}
+	if hub.joinRoom(alice, "general") {
+		t.Fatal("expected duplicate join to be a no-op")
+	}
+
+	members := hub.roomMembers("general")
+	if len(members) != 1 || members[0] != "alice" {
+		t.Fatalf("expected [alice] in general, got %v", members)
+	}
+
+	if !hub.leaveRoom(alice, "general") {
+		t.Fatal("expected leave to succeed")
+	}
+	if hub.leaveRoom(alice, "general") {
+		t.Fatal("expected leaving twice to be a no-op")
+	}
+	if members := hub.roomMembers("general"); len(members) != 0 {
+		t.Fatalf("expected general to be empty, got %v", members)
+	}
+	if rooms := hub.roomList(); len(rooms) != 0 {
+		t.Fatalf("expected no rooms left after last member leaves, got %v", rooms)
+	}
+}
+
+func TestCrossRoomIsolation(t *testing.T) {
+	hub := NewHub()
+	alice := newTestClient("alice")
+	bob := newTestClient("bob")
+
+	hub.joinRoom(alice, "general")
+	hub.joinRoom(bob, "random")
+
+	hub.broadcastRoom("general", "alice", "MSG general alice hi")
+
+	select {
+	case msg := <-bob.out:
+		t.Fatalf("bob should not receive messages from #general, got %q", msg)
+	default:
+	}
+
+	select {
+	case msg := <-alice.out:
+		t.Fatalf("sender should not receive its own broadcast, got %q", msg)
+	default:
+	}
+
+	hub.joinRoom(bob, "general")
+	hub.broadcastRoom("general", "alice", "MSG general alice hello again")
+	select {
+	case msg := <-bob.out:
+		if msg != "MSG general alice hello again" {
+			t.Fatalf("unexpected message: %q", msg)
+		}
+	default:
+		t.Fatal("expected bob to receive the #general broadcast after joining")
+	}
+}
+
+func TestCleanupOnDisconnect(t *testing.T) {
+	hub := NewHub()
+	alice := newTestClient("alice")
+
+	if err := hub.addUser(alice); err != nil {
+		t.Fatalf("addUser: %v", err)
+	}
+	hub.joinRoom(alice, "general")
+	hub.joinRoom(alice, "random")
+
+	hub.removeUser("alice")
+
+	if rooms := hub.roomList(); len(rooms) != 0 {
+		t.Fatalf("expected all rooms emptied after disconnect, got %v", rooms)
+	}
+	if _, ok := hub.users["alice"]; ok {
+		t.Fatal("expected alice to be removed from hub.users")
+	}
+}
+
+func TestHistoryReplayOnJoin(t *testing.T) {
+	hub := NewHub()
+	hub.enableHistory(10, nil)
+	cfg := &Config{historySize: 10}
+
+	alice := newTestClient("alice")
+	hub.addUser(alice)
+	dispatchLine(hub, cfg, alice, "JOIN #general")
+	dispatchLine(hub, cfg, alice, "MSG #general hello")
+	dispatchLine(hub, cfg, alice, "MSG #general world")
+
+	bob := newTestClient("bob")
+	hub.addUser(bob)
+	dispatchLine(hub, cfg, bob, "JOIN #general")
+
+	got := bob.conn.(*testConn).lines()
+	wantHello := "MSG general alice hello"
+	wantWorld := "MSG general alice world"
+	foundHello, foundWorld := false, false
+	for _, line := range got {
+		if line == wantHello {
+			foundHello = true
+		}
+		if line == wantWorld {
+			foundWorld = true
+		}
+	}
+	if !foundHello || !foundWorld {
+		t.Fatalf("expected bob to receive replayed history on join, got %v", got)
+	}
+	// Replay must preserve chronological order.
+	idxHello, idxWorld := -1, -1
+	for i, line := range got {
+		if line == wantHello {
+			idxHello = i
+		}
+		if line == wantWorld {
+			idxWorld = i
+		}
+	}
+	if idxHello > idxWorld {
+		t.Fatalf("expected replay in chronological order, got %v", got)
+	}
+}
+
+func TestHistoryCommand(t *testing.T) {
+	hub := NewHub()
+	hub.enableHistory(10, nil)
+	cfg := &Config{historySize: 10}
+
+	alice := newTestClient("alice")
+	hub.addUser(alice)
+	dispatchLine(hub, cfg, alice, "JOIN #general")
+	dispatchLine(hub, cfg, alice, "MSG #general first")
+	dispatchLine(hub, cfg, alice, "MSG #general second")
+
+	bob := newTestClient("bob")
+	hub.addUser(bob)
+	dispatchLine(hub, cfg, bob, "HISTORY #general 1")
+
+	got := bob.conn.(*testConn).lines()
+	if len(got) == 0 || got[0] != "MSG general alice second" {
+		t.Fatalf("expected HISTORY #general 1 to return only the most recent line, got %v", got)
+	}
+}
+
+func TestHistoryConcurrentBroadcastAndJoin(t *testing.T) {
+	hub := NewHub()
+	hub.enableHistory(20, nil)
+	cfg := &Config{historySize: 20}
+
+	alice := newTestClient("alice")
+	hub.addUser(alice)
+	dispatchLine(hub, cfg, alice, "JOIN #general")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dispatchLine(hub, cfg, alice, fmt.Sprintf("MSG #general msg%d", i))
+		}(i)
+	}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			joiner := newTestClient(fmt.Sprintf("joiner%d", i))
+			hub.addUser(joiner)
+			dispatchLine(hub, cfg, joiner, "JOIN #general")
+		}(i)
+	}
+	wg.Wait()
+
+	// No assertion beyond "doesn't race or panic"; -race is what actually
+	// exercises this test's purpose.
+}
+
+// pipeWriterConn is a connStream around an io.PipeWriter, letting a test
+// observe exactly what bytes clientWriter and writeSafe put on the wire.
+type pipeWriterConn struct {
+	*io.PipeWriter
+}
+
+func (c *pipeWriterConn) Read(p []byte) (int, error) { return 0, io.EOF }
+func (c *pipeWriterConn) RemoteAddr() net.Addr       { return &net.TCPAddr{} }
+
+// TestBinaryWritesDontInterleave guards against clientWriter (draining out)
+// and writeSafe (direct replies, e.g. PONG/OK/ERR) racing to write
+// wire.Frames to the same conn: each frame is two conn.Write calls, so an
+// unsynchronized interleaving would permanently desync the binary stream.
+func TestBinaryWritesDontInterleave(t *testing.T) {
+	r, w := io.Pipe()
+	client := &Client{
+		username: "alice",
+		conn:     &pipeWriterConn{w},
+		proto:    protoBinary,
+		out:      make(chan string, 1),
+	}
+	done := make(chan struct{})
+	go clientWriter(client, done)
+	defer close(done)
+
+	const n = 200
+	var senders sync.WaitGroup
+	senders.Add(2)
+	go func() {
+		defer senders.Done()
+		for i := 0; i < n; i++ {
+			client.out <- fmt.Sprintf("MSG #general queued %d", i)
+		}
+	}()
+	go func() {
+		defer senders.Done()
+		for i := 0; i < n; i++ {
+			writeSafe(client, fmt.Sprintf("OK direct %d", i))
+		}
+	}()
+
+	readErr := make(chan error, 1)
+	go func() {
+		for i := 0; i < 2*n; i++ {
+			if _, err := wire.ReadFrame(r); err != nil {
+				readErr <- err
+				return
+			}
+		}
+		readErr <- nil
+	}()
+
+	senders.Wait()
+	if err := <-readErr; err != nil {
+		t.Fatalf("binary frame stream desynced: %v", err)
+	}
+}
+
+func TestIsAdminMatchesUsernameOrFingerprint(t *testing.T) {
+	cfg := &Config{admins: parseAdmins("alice,SHA256:abc123")}
+
+	byUsername := newTestClient("alice")
+	if !cfg.isAdmin(byUsername) {
+		t.Fatal("expected a client whose username is in --admin to be an admin")
+	}
+
+	byFingerprint := newTestClient("bob")
+	byFingerprint.fingerprint = "SHA256:abc123"
+	if !cfg.isAdmin(byFingerprint) {
+		t.Fatal("expected a client whose key fingerprint is in --admin to be an admin")
+	}
+
+	neither := newTestClient("carol")
+	if cfg.isAdmin(neither) {
+		t.Fatal("expected a client matching neither username nor fingerprint to not be an admin")
+	}
+}
+
+func TestDetectProto(t *testing.T) {
+	frame := wire.Frame{Op: wire.OpMSG, Payload: []byte("MSG #general hi")}
+	var buf bytes.Buffer
+	if err := wire.WriteFrame(&buf, frame); err != nil {
+		t.Fatalf("WriteFrame: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"binary frame", buf.Bytes(), protoBinary},
+		{"text login line", []byte("LOGIN alice\n"), protoText},
+	}
+	for _, tc := range cases {
+		r := bufio.NewReader(bytes.NewReader(tc.in))
+		got, err := detectProto(r)
+		if err != nil {
+			t.Fatalf("%s: detectProto: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: detectProto = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseRoomArg(t *testing.T) {
+	cases := []struct {
+		in       string
+		wantRoom string
+		wantOK   bool
+	}{
+		{"#general hello there", "general", true},
+		{"#general", "general", true},
+		{"hello there", "", false},
+		{"#", "", false},
+		{"", "", false},
+	}
+	for _, tc := range cases {
+		room, ok := parseRoomArg(tc.in)
+		if room != tc.wantRoom || ok != tc.wantOK {
+			t.Errorf("parseRoomArg(%q) = (%q, %v), want (%q, %v)", tc.in, room, ok, tc.wantRoom, tc.wantOK)
+		}
+	}
+}
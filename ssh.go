@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// serveSSH accepts SSH connections on ln, terminating each session and
+// handing its channel's byte stream to handleConn exactly like a plain TCP
+// connection would be, so the existing command parser is reused unchanged.
+func serveSSH(ln net.Listener, hostKeyPath string, hub *Hub, cfg *Config) error {
+	signer, err := loadOrCreateHostKey(hostKeyPath)
+	if err != nil {
+		return fmt.Errorf("load host key: %w", err)
+	}
+
+	sshConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			return &ssh.Permissions{
+				Extensions: map[string]string{"fingerprint": keyFingerprint(key)},
+			}, nil
+		},
+	}
+	sshConfig.AddHostKey(signer)
+
+	for {
+		tcpConn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go acceptSSHConn(tcpConn, sshConfig, hub, cfg)
+	}
+}
+
+func acceptSSHConn(tcpConn net.Conn, sshConfig *ssh.ServerConfig, hub *Hub, cfg *Config) {
+	sc, chans, reqs, err := ssh.NewServerConn(tcpConn, sshConfig)
+	if err != nil {
+		log.Printf("[SSH] handshake failed from %s: %v", tcpConn.RemoteAddr(), err)
+		tcpConn.Close()
+		return
+	}
+	defer sc.Close()
+	go ssh.DiscardRequests(reqs)
+
+	var fingerprint string
+	if sc.Permissions != nil {
+		fingerprint = sc.Permissions.Extensions["fingerprint"]
+	}
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			log.Printf("[SSH] channel accept failed for %s: %v", sc.User(), err)
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+
+		stream := &sshChannelConn{Channel: channel, remoteAddr: tcpConn.RemoteAddr()}
+		identity := &sshIdentity{username: sc.User(), fingerprint: fingerprint}
+		go handleConn(hub, cfg, stream, identity)
+	}
+}
+
+// sshChannelConn adapts an ssh.Channel (which has no notion of a peer
+// address) to connStream by pinning the address of the TCP connection the
+// channel's session was negotiated over.
+type sshChannelConn struct {
+	ssh.Channel
+	remoteAddr net.Addr
+}
+
+func (c *sshChannelConn) RemoteAddr() net.Addr { return c.remoteAddr }
+
+// loadOrCreateHostKey reads an SSH host private key from path, generating
+// and persisting a new RSA key there if none exists yet.
+func loadOrCreateHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(key)
+}
+
+// keyFingerprint returns a stable SHA-256 fingerprint for a public key, in
+// the same form `ssh-keygen -lf` prints.
+func keyFingerprint(key ssh.PublicKey) string {
+	sum := sha256.Sum256(key.Marshal())
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
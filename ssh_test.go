@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOrCreateHostKeyGeneratesAndReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "host_key")
+
+	signer, err := loadOrCreateHostKey(path)
+	if err != nil {
+		t.Fatalf("loadOrCreateHostKey (generate): %v", err)
+	}
+
+	reloaded, err := loadOrCreateHostKey(path)
+	if err != nil {
+		t.Fatalf("loadOrCreateHostKey (reload): %v", err)
+	}
+
+	if string(reloaded.PublicKey().Marshal()) != string(signer.PublicKey().Marshal()) {
+		t.Fatal("expected reloaded host key to match the generated one")
+	}
+}
+
+func TestKeyFingerprintStable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "host_key")
+	signer, err := loadOrCreateHostKey(path)
+	if err != nil {
+		t.Fatalf("loadOrCreateHostKey: %v", err)
+	}
+
+	fp1 := keyFingerprint(signer.PublicKey())
+	fp2 := keyFingerprint(signer.PublicKey())
+	if fp1 != fp2 {
+		t.Fatalf("expected stable fingerprint, got %q and %q", fp1, fp2)
+	}
+	if fp1[:7] != "SHA256:" {
+		t.Fatalf("expected fingerprint to be SHA256-prefixed, got %q", fp1)
+	}
+}
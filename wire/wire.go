@@ -0,0 +1,132 @@
+// Package wire implements the server's length-prefixed binary framing, an
+// alternative to the default newline-delimited text protocol for clients
+// that need to send payloads containing newlines or raw bytes.
+//
+// A frame is a 4-byte big-endian length (covering the opcode and payload
+// that follow), a 1-byte opcode, and the payload itself:
+//
+//	+----------+--------+-----------------+
+//	| length=N | opcode | payload (N-1 B) |
+//	+----------+--------+-----------------+
+//
+// The payload carries the exact same command line the text protocol would
+// send (e.g. "MSG #general hello"), so both wire formats decode to
+// identical internal command structs; the opcode is just a fast dispatch
+// tag derived from the line's leading verb.
+package wire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Opcode tags a Frame with the kind of command its payload carries.
+type Opcode byte
+
+const (
+	OpMSG Opcode = iota + 1
+	OpDM
+	OpJoin
+	OpLeave
+	OpPing
+	OpPong
+	OpWho
+	OpErr
+	OpOK
+	// OpInfo tags anything outside the core command set above (INFO, USER,
+	// ROOM, BANNED, and other server-to-client informational lines).
+	OpInfo
+)
+
+var opcodeNames = map[Opcode]string{
+	OpMSG: "MSG", OpDM: "DM", OpJoin: "JOIN", OpLeave: "LEAVE",
+	OpPing: "PING", OpPong: "PONG", OpWho: "WHO", OpErr: "ERR", OpOK: "OK",
+	OpInfo: "INFO",
+}
+
+var verbToOpcode = func() map[string]Opcode {
+	m := make(map[string]Opcode, len(opcodeNames))
+	for op, name := range opcodeNames {
+		m[name] = op
+	}
+	return m
+}()
+
+func (o Opcode) String() string {
+	if name, ok := opcodeNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("Opcode(%d)", o)
+}
+
+// OpcodeForVerb maps a text-protocol line's leading verb to its binary
+// opcode, falling back to OpInfo for verbs outside the core command set.
+func OpcodeForVerb(verb string) Opcode {
+	if op, ok := verbToOpcode[strings.ToUpper(verb)]; ok {
+		return op
+	}
+	return OpInfo
+}
+
+// maxFrameSize bounds a frame's declared length, guarding against a
+// corrupt or hostile length prefix driving an unbounded allocation.
+const maxFrameSize = 1 << 20 // 1 MiB
+
+// Frame is one length-prefixed binary message.
+type Frame struct {
+	Op      Opcode
+	Payload []byte
+}
+
+// EncodeLine wraps a text-protocol command line in a Frame, tagging it
+// with the opcode of its leading verb.
+func EncodeLine(line string) Frame {
+	verb := line
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		verb = line[:i]
+	}
+	return Frame{Op: OpcodeForVerb(verb), Payload: []byte(line)}
+}
+
+// Line returns the frame's payload as a text-protocol command line.
+func (f Frame) Line() string {
+	return string(f.Payload)
+}
+
+// WriteFrame writes f to w as a length-prefixed binary frame.
+func WriteFrame(w io.Writer, f Frame) error {
+	body := make([]byte, 1+len(f.Payload))
+	body[0] = byte(f.Op)
+	copy(body[1:], f.Payload)
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(body)))
+	if _, err := w.Write(length[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// ReadFrame reads one length-prefixed binary frame from r.
+func ReadFrame(r io.Reader) (Frame, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return Frame{}, err
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if n == 0 {
+		return Frame{}, fmt.Errorf("wire: empty frame")
+	}
+	if n > maxFrameSize {
+		return Frame{}, fmt.Errorf("wire: frame of %d bytes exceeds %d byte limit", n, maxFrameSize)
+	}
+
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return Frame{}, err
+	}
+	return Frame{Op: Opcode(body[0]), Payload: body[1:]}, nil
+}
@@ -0,0 +1,93 @@
+package wire
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	cases := []string{
+		"MSG #general hello there",
+		"DM alice hi",
+		"JOIN #general",
+		"LEAVE #general",
+		"PING ab12",
+		"PONG ab12",
+		"WHO #general",
+		"ERR banned",
+		"OK",
+		"INFO alice joined #general",
+		"MSG #general line one\nline two", // payload may contain raw newlines
+	}
+
+	for _, line := range cases {
+		var buf bytes.Buffer
+		if err := WriteFrame(&buf, EncodeLine(line)); err != nil {
+			t.Fatalf("WriteFrame(%q): %v", line, err)
+		}
+		got, err := ReadFrame(&buf)
+		if err != nil {
+			t.Fatalf("ReadFrame(%q): %v", line, err)
+		}
+		if got.Line() != line {
+			t.Fatalf("round trip mismatch: got %q, want %q", got.Line(), line)
+		}
+	}
+}
+
+func TestOpcodeForVerb(t *testing.T) {
+	cases := map[string]Opcode{
+		"MSG":   OpMSG,
+		"msg":   OpMSG,
+		"DM":    OpDM,
+		"JOIN":  OpJoin,
+		"LEAVE": OpLeave,
+		"PING":  OpPing,
+		"PONG":  OpPong,
+		"WHO":   OpWho,
+		"ERR":   OpErr,
+		"OK":    OpOK,
+		"INFO":  OpInfo,
+		"ROOM":  OpInfo,
+		"USER":  OpInfo,
+	}
+	for verb, want := range cases {
+		if got := OpcodeForVerb(verb); got != want {
+			t.Errorf("OpcodeForVerb(%q) = %v, want %v", verb, got, want)
+		}
+	}
+}
+
+func TestReadFrameRejectsOversizedLength(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF}) // declares a ~4GiB frame
+	if _, err := ReadFrame(&buf); err == nil {
+		t.Fatal("expected an oversized frame to be rejected")
+	}
+}
+
+func TestReadFrameRejectsEmptyLength(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write([]byte{0, 0, 0, 0})
+	if _, err := ReadFrame(&buf); err == nil {
+		t.Fatal("expected a zero-length frame to be rejected")
+	}
+}
+
+func TestMultipleFramesOnOneStream(t *testing.T) {
+	var buf bytes.Buffer
+	WriteFrame(&buf, EncodeLine("PING a"))
+	WriteFrame(&buf, EncodeLine("PING b"))
+
+	f1, err := ReadFrame(&buf)
+	if err != nil {
+		t.Fatalf("ReadFrame 1: %v", err)
+	}
+	f2, err := ReadFrame(&buf)
+	if err != nil {
+		t.Fatalf("ReadFrame 2: %v", err)
+	}
+	if f1.Line() != "PING a" || f2.Line() != "PING b" {
+		t.Fatalf("got %q, %q", f1.Line(), f2.Line())
+	}
+}